@@ -1,8 +1,14 @@
 package openapi
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
 	"slices"
 	"strings"
 
@@ -10,14 +16,31 @@ import (
 	"github.com/hasura/ndc-rest-schema/utils"
 	"github.com/hasura/ndc-sdk-go/schema"
 	"github.com/pb33f/libopenapi"
+	validator "github.com/pb33f/libopenapi-validator"
 	"github.com/pb33f/libopenapi/datamodel/high/base"
 	v2 "github.com/pb33f/libopenapi/datamodel/high/v2"
 	"github.com/pb33f/libopenapi/orderedmap"
+	"gopkg.in/yaml.v3"
 )
 
 type openAPIv2Converter struct {
 	schema *rest.NDCRestSchema
 	*ConvertOptions
+	// docConsumes/docProduces are the document-level fallbacks operations use
+	// when they don't declare their own consumes/produces.
+	docConsumes []string
+	docProduces []string
+	// externalRefNamespaces caches the namespace assigned to each external
+	// ref source file, so repeated refs into the same file share one
+	// internalization pass instead of re-fetching and re-naming it.
+	externalRefNamespaces map[string]string
+}
+
+// Logger receives non-fatal conversion problems that are downgraded to
+// warnings, instead of aborting the conversion, when ConvertOptions.Strict
+// is off.
+type Logger interface {
+	Warnf(format string, args ...any)
 }
 
 // OpenAPIv2ToNDCSchema converts OpenAPI v2 JSON bytes to NDC REST schema
@@ -26,16 +49,54 @@ func OpenAPIv2ToNDCSchema(input []byte, options *ConvertOptions) (*rest.NDCRestS
 	if err != nil {
 		return nil, []error{err}
 	}
+
+	if opts.Upgrade {
+		// many Swagger 2 features (multi-content responses, oneOf, form-data refs)
+		// only have a first-class representation in v3, so delegate to that
+		// converter instead of maintaining the translation in two code paths
+		v3Input, err := upgradeV2ToV3(input)
+		if err != nil {
+			return nil, []error{fmt.Errorf("failed to upgrade OpenAPI 2 document to 3: %w", err)}
+		}
+		return OpenAPIv3ToNDCSchema(v3Input, opts)
+	}
+
 	document, err := libopenapi.NewDocument(input)
 	if err != nil {
 		return nil, []error{err}
 	}
 
+	if opts.Strict {
+		docValidator, valErrs := validator.NewValidator(document)
+		if len(valErrs) > 0 {
+			return nil, valErrs
+		}
+		if valid, validationErrs := docValidator.ValidateDocument(); !valid {
+			// in strict mode, a document that fails the Swagger 2.0 meta-schema
+			// is rejected outright rather than converted best-effort
+			metaErrs := make([]error, 0, len(validationErrs))
+			for _, e := range validationErrs {
+				metaErrs = append(metaErrs, errors.New(e.Message))
+			}
+			return nil, metaErrs
+		}
+	}
+
 	docModel, errs := document.BuildV2Model()
 	// The errors won’t prevent the model from building
 	if docModel == nil && len(errs) > 0 {
 		return nil, errs
 	}
+	if opts.Strict && len(errs) > 0 {
+		// in strict mode, errors from building the model (malformed refs,
+		// unresolvable schemas, etc.) are also fatal rather than best-effort
+		// warnings
+		return nil, errs
+	}
+	for _, e := range errs {
+		opts.warnf("%s", e)
+	}
+	errs = nil
 
 	if docModel.Model.Paths == nil || docModel.Model.Paths.PathItems == nil || docModel.Model.Paths.PathItems.IsZero() {
 		return nil, append(errs, errors.New("there is no API to be converted"))
@@ -44,6 +105,8 @@ func OpenAPIv2ToNDCSchema(input []byte, options *ConvertOptions) (*rest.NDCRestS
 	converter := &openAPIv2Converter{
 		schema:         rest.NewNDCRestSchema(),
 		ConvertOptions: opts,
+		docConsumes:    docModel.Model.Consumes,
+		docProduces:    docModel.Model.Produces,
 	}
 	if docModel.Model.Info != nil {
 		converter.schema.Settings.Version = docModel.Model.Info.Version
@@ -66,14 +129,22 @@ func OpenAPIv2ToNDCSchema(input []byte, options *ConvertOptions) (*rest.NDCRestS
 
 	for iterPath := docModel.Model.Paths.PathItems.First(); iterPath != nil; iterPath = iterPath.Next() {
 		if err := converter.pathToNDCOperations(iterPath); err != nil {
-			return nil, append(errs, err)
+			if opts.Strict {
+				errs = append(errs, err)
+				continue
+			}
+			opts.warnf("skipping path %s: %s", iterPath.Key(), err)
 		}
 	}
 
 	if docModel.Model.Definitions != nil {
 		for cSchema := docModel.Model.Definitions.Definitions.First(); cSchema != nil; cSchema = cSchema.Next() {
 			if err := converter.convertComponentSchemas(cSchema); err != nil {
-				return nil, append(errs, err)
+				if opts.Strict {
+					errs = append(errs, err)
+					continue
+				}
+				opts.warnf("skipping definition %s: %s", cSchema.Key(), err)
 			}
 		}
 	}
@@ -83,16 +154,34 @@ func OpenAPIv2ToNDCSchema(input []byte, options *ConvertOptions) (*rest.NDCRestS
 		for scheme := docModel.Model.SecurityDefinitions.Definitions.First(); scheme != nil; scheme = scheme.Next() {
 			err := converter.convertSecuritySchemes(scheme)
 			if err != nil {
-				return nil, append(errs, err)
+				if opts.Strict {
+					errs = append(errs, err)
+					continue
+				}
+				opts.warnf("skipping security scheme %s: %s", scheme.Key(), err)
 			}
 		}
 	}
 
+	if opts.Strict && len(errs) > 0 {
+		return nil, errs
+	}
+
 	converter.schema.Settings.Security = convertSecurities(docModel.Model.Security)
 
 	return converter.schema, nil
 }
 
+// warnf downgrades a recoverable conversion problem to a warning through the
+// pluggable Logger rather than aborting the whole conversion; only used
+// outside strict mode, where the caller keeps collecting a best-effort schema.
+func (opts *ConvertOptions) warnf(format string, args ...any) {
+	if opts.Logger == nil {
+		return
+	}
+	opts.Logger.Warnf(format, args...)
+}
+
 func (oc *openAPIv2Converter) convertSecuritySchemes(scheme orderedmap.Pair[string, *v2.SecurityScheme]) error {
 	key := scheme.Key()
 	security := scheme.Value()
@@ -171,7 +260,7 @@ func (oc *openAPIv2Converter) pathToNDCOperations(pathItem orderedmap.Pair[strin
 		if funcName == "" {
 			funcName = buildPathMethodName(pathKey, "get", oc.ConvertOptions)
 		}
-		resultType, err := oc.convertResponse(itemGet.Responses, pathKey, []string{funcName, "Result"})
+		resultType, responseFormat, err := oc.convertResponses(itemGet.Responses, pathKey, []string{funcName})
 		if err != nil {
 			return fmt.Errorf("%s: %s", pathKey, err)
 		}
@@ -181,13 +270,24 @@ func (oc *openAPIv2Converter) pathToNDCOperations(pathItem orderedmap.Pair[strin
 				return fmt.Errorf("%s: %s", funcName, err)
 			}
 
+			consumes, produces := oc.resolveMediaTypes(itemGet.Consumes, itemGet.Produces)
+			if reqBody != nil && len(consumes) > 0 {
+				reqBody.ContentType = consumes[0]
+			}
+			// GET carries no request body, so negotiating its content type is
+			// meaningless; only the response side can vary.
+			addMediaTypeArgument(arguments, "_accept", produces, "Expected response content type")
+
 			function := rest.RESTFunctionInfo{
 				Request: &rest.Request{
-					URL:         pathKey,
-					Method:      "get",
-					Parameters:  reqParams,
-					RequestBody: reqBody,
-					Security:    convertSecurities(itemGet.Security),
+					URL:            pathKey,
+					Method:         "get",
+					Parameters:     reqParams,
+					RequestBody:    reqBody,
+					Security:       convertSecurities(itemGet.Security),
+					ContentTypes:   consumes,
+					Accepts:        produces,
+					ResponseFormat: responseFormat,
 				},
 				FunctionInfo: schema.FunctionInfo{
 					Name:       funcName,
@@ -249,7 +349,7 @@ func (oc *openAPIv2Converter) convertProcedureOperation(pathKey string, method s
 		procName = buildPathMethodName(pathKey, method, oc.ConvertOptions)
 	}
 
-	resultType, err := oc.convertResponse(operation.Responses, pathKey, []string{procName, "Result"})
+	resultType, responseFormat, err := oc.convertResponses(operation.Responses, pathKey, []string{procName})
 	if err != nil {
 		return nil, fmt.Errorf("%s: %s", pathKey, err)
 	}
@@ -263,21 +363,23 @@ func (oc *openAPIv2Converter) convertProcedureOperation(pathKey string, method s
 		return nil, fmt.Errorf("%s: %s", pathKey, err)
 	}
 
-	if reqBody != nil && len(operation.Consumes) > 0 {
-		contentType := rest.ContentTypeJSON
-		if !slices.Contains(operation.Consumes, rest.ContentTypeJSON) {
-			contentType = operation.Consumes[0]
-		}
-		reqBody.ContentType = contentType
+	consumes, produces := oc.resolveMediaTypes(operation.Consumes, operation.Produces)
+	if reqBody != nil && len(consumes) > 0 {
+		reqBody.ContentType = consumes[0]
 	}
+	addMediaTypeArgument(arguments, "_content_type", consumes, "Request content type")
+	addMediaTypeArgument(arguments, "_accept", produces, "Expected response content type")
 
 	procedure := rest.RESTProcedureInfo{
 		Request: &rest.Request{
-			URL:         pathKey,
-			Method:      method,
-			Parameters:  reqParams,
-			RequestBody: reqBody,
-			Security:    convertSecurities(operation.Security),
+			URL:            pathKey,
+			Method:         method,
+			Parameters:     reqParams,
+			RequestBody:    reqBody,
+			Security:       convertSecurities(operation.Security),
+			ContentTypes:   consumes,
+			Accepts:        produces,
+			ResponseFormat: responseFormat,
 		},
 		ProcedureInfo: schema.ProcedureInfo{
 			Name:       procName,
@@ -417,7 +519,10 @@ func (oc *openAPIv2Converter) getSchemaTypeFromProxy(schemaProxy *base.SchemaPro
 	var typeSchema *rest.TypeSchema
 	var err error
 
-	refName := getSchemaRefTypeNameV2(schemaProxy.GetReference())
+	refName, err := oc.resolveSchemaRefName(schemaProxy.GetReference())
+	if err != nil {
+		return nil, nil, err
+	}
 	// return early object from ref
 	if refName != "" && len(innerSchema.Type) > 0 && innerSchema.Type[0] == "object" {
 		ndcType = schema.NewNamedType(utils.ToPascalCase(refName))
@@ -488,13 +593,16 @@ func (oc *openAPIv2Converter) getSchemaType(typeSchema *base.Schema, apiPath str
 		return schema.NewNamedType(scalarName), typeResult, nil
 	}
 
-	if len(typeSchema.Type) == 0 {
+	if len(typeSchema.Type) == 0 && len(typeSchema.AllOf) == 0 {
 		return nil, nil, errParameterSchemaEmpty
 	}
 
 	var result schema.TypeEncoder
-	typeName := typeSchema.Type[0]
-	if isPrimitiveScalar(typeName) {
+	typeName := "object"
+	if len(typeSchema.Type) > 0 {
+		typeName = typeSchema.Type[0]
+	}
+	if len(typeSchema.AllOf) == 0 && isPrimitiveScalar(typeName) {
 		scalarName := getScalarFromType(oc.schema, typeSchema.Type, typeSchema.Format, typeSchema.Enum, oc.trimPathPrefix(apiPath), fieldPaths)
 		result = schema.NewNamedType(scalarName)
 		typeResult = createSchemaFromOpenAPISchema(typeSchema, scalarName)
@@ -506,7 +614,8 @@ func (oc *openAPIv2Converter) getSchemaType(typeSchema *base.Schema, apiPath str
 		case "object":
 			refName := utils.StringSliceToPascalCase(fieldPaths)
 
-			if typeSchema.Properties == nil || typeSchema.Properties.IsZero() {
+			flattened := oc.flattenAllOf(typeSchema)
+			if len(flattened.properties) == 0 {
 				// treat no-property objects as a JSON scalar
 				oc.schema.ScalarTypes[refName] = *schema.NewScalarType()
 			} else {
@@ -518,9 +627,14 @@ func (oc *openAPIv2Converter) getSchemaType(typeSchema *base.Schema, apiPath str
 				}
 
 				typeResult.Properties = make(map[string]rest.TypeSchema)
-				for prop := typeSchema.Properties.First(); prop != nil; prop = prop.Next() {
+				for _, prop := range flattened.properties {
 					propName := prop.Key()
-					nullable := !slices.Contains(typeSchema.Required, propName)
+					nullable := !slices.Contains(flattened.required, propName)
+					if isReadOnlyOrWriteOnly(prop.Value()) {
+						// a property only ever present on one side of the wire can't be
+						// required on both, so never force uploads to supply it (kin-openapi#246)
+						nullable = true
+					}
 					propType, propApiSchema, err := oc.getSchemaTypeFromProxy(prop.Value(), nullable, apiPath, append(fieldPaths, propName))
 					if err != nil {
 						return nil, nil, err
@@ -536,6 +650,10 @@ func (oc *openAPIv2Converter) getSchemaType(typeSchema *base.Schema, apiPath str
 					object.Fields[propName] = objField
 				}
 
+				if typeSchema.Discriminator != nil && typeSchema.Discriminator.PropertyName != "" {
+					oc.addDiscriminatorTag(&object, typeResult, refName, typeSchema.Discriminator)
+				}
+
 				oc.schema.ObjectTypes[refName] = object
 			}
 			result = schema.NewNamedType(refName)
@@ -544,7 +662,10 @@ func (oc *openAPIv2Converter) getSchemaType(typeSchema *base.Schema, apiPath str
 				return nil, nil, errors.New("array item is empty")
 			}
 
-			itemName := getSchemaRefTypeNameV2(typeSchema.Items.A.GetReference())
+			itemName, err := oc.resolveSchemaRefName(typeSchema.Items.A.GetReference())
+			if err != nil {
+				return nil, nil, err
+			}
 			if itemName != "" {
 				result = schema.NewArrayType(schema.NewNamedType(itemName))
 			} else {
@@ -574,51 +695,756 @@ func (oc *openAPIv2Converter) getSchemaType(typeSchema *base.Schema, apiPath str
 	return result, typeResult, nil
 }
 
-func (oc *openAPIv2Converter) convertResponse(responses *v2.Responses, apiPath string, fieldPaths []string) (schema.TypeEncoder, error) {
+// responseVariant is one documented status code of an operation, resolved
+// to the NDC type its response body (or error body) encodes.
+type responseVariant struct {
+	statusCode string
+	fieldName  string
+	resultType schema.TypeEncoder
+}
+
+// convertResponses builds the operation's result type from every documented
+// response rather than only the first of 200/201/204. A lone 2xx response
+// with nothing else documented is returned unwrapped, as its own type (an
+// inline schema is named after its variant field, e.g. `<op>Result200`);
+// otherwise every success (2xx) and error (every other code, plus default)
+// response is folded
+// into one tagged union object carrying a `status_code` field plus one
+// nullable field per variant, mirroring go-swagger's sortedResponses
+// treatment where every declared code is first-class. Folding errors into
+// the same ResultType keeps them reachable through the operation info that
+// already wires it up, instead of a second object type nothing references.
+func (oc *openAPIv2Converter) convertResponses(responses *v2.Responses, apiPath string, fieldPaths []string) (schema.TypeEncoder, *rest.ResponseFormat, error) {
 	if responses == nil || responses.Codes == nil || responses.Codes.IsZero() {
-		return nil, nil
+		return nil, nil, nil
 	}
 
-	var resp *v2.Response
-	if responses.Codes == nil || responses.Codes.IsZero() {
-		// the response is alway success
-		resp = responses.Default
-	} else {
-		for _, code := range []string{"200", "201", "204"} {
-			res := responses.Codes.GetOrZero(code)
-			if res != nil {
-				resp = res
-				break
-			}
+	var successVariants, errorVariants []responseVariant
+	for code := responses.Codes.First(); code != nil; code = code.Next() {
+		statusCode := code.Key()
+		prefix := "result"
+		if !strings.HasPrefix(statusCode, "2") {
+			prefix = "error"
+		}
+		variant, err := oc.buildResponseVariant(prefix, statusCode, code.Value(), apiPath, fieldPaths)
+		if err != nil {
+			return nil, nil, err
 		}
+		if prefix == "result" {
+			successVariants = append(successVariants, variant)
+		} else {
+			errorVariants = append(errorVariants, variant)
+		}
+	}
+	if responses.Default != nil {
+		variant, err := oc.buildResponseVariant("error", "default", responses.Default, apiPath, fieldPaths)
+		if err != nil {
+			return nil, nil, err
+		}
+		errorVariants = append(errorVariants, variant)
 	}
 
-	// return nullable boolean type if the response content is null
+	switch {
+	case len(successVariants) == 0 && len(errorVariants) == 0:
+		// return nullable boolean type if the response content is null
+		return schema.NewNullableNamedType("Boolean"), nil, nil
+	case len(successVariants) == 1 && len(errorVariants) == 0:
+		return successVariants[0].resultType, nil, nil
+	default:
+		// Multiple success codes, error-only responses, or a mix of both:
+		// fold every variant into a single tagged union so the error branches
+		// ride along on the same ResultType callers already wire up, rather
+		// than a second object type nothing references. ResponseFormat tells
+		// the runtime which field to decode into for a given status code.
+		variants := append(successVariants, errorVariants...)
+		resultType := oc.buildResultUnionType(fieldPaths, "Result", variants)
+		return resultType, buildResponseFormat(variants), nil
+	}
+}
+
+// buildResponseFormat records the status_code -> variant field dispatch the
+// runtime needs to decode a tagged-union ResultType produced by
+// buildResultUnionType.
+func buildResponseFormat(variants []responseVariant) *rest.ResponseFormat {
+	dispatch := make(map[string]string, len(variants))
+	for _, variant := range variants {
+		dispatch[variant.statusCode] = variant.fieldName
+	}
+	return &rest.ResponseFormat{
+		TagField: "status_code",
+		Variants: dispatch,
+	}
+}
+
+// buildResponseVariant resolves a single status code's response body to its
+// NDC type, naming the field it'll occupy in a tagged union after the code.
+func (oc *openAPIv2Converter) buildResponseVariant(prefix string, statusCode string, resp *v2.Response, apiPath string, fieldPaths []string) (responseVariant, error) {
+	fieldName := fmt.Sprintf("%s_%s", prefix, strings.ToLower(statusCode))
+	var resultType schema.TypeEncoder
 	if resp == nil || resp.Schema == nil {
-		return schema.NewNullableNamedType("Boolean"), nil
+		resultType = schema.NewNullableNamedType("Boolean")
+	} else {
+		var err error
+		resultType, _, err = oc.getSchemaTypeFromProxy(resp.Schema, false, apiPath, append(fieldPaths, utils.ToPascalCase(fieldName)))
+		if err != nil {
+			return responseVariant{}, err
+		}
 	}
+	return responseVariant{statusCode: statusCode, fieldName: fieldName, resultType: resultType}, nil
+}
 
-	schemaType, _, err := oc.getSchemaTypeFromProxy(resp.Schema, false, apiPath, fieldPaths)
-	if err != nil {
-		return nil, err
+// buildResultUnionType materializes a tagged union object over several
+// response variants, named `<fieldPaths><suffix>`, with a `status_code`
+// scalar tag plus one nullable field per variant.
+func (oc *openAPIv2Converter) buildResultUnionType(fieldPaths []string, suffix string, variants []responseVariant) schema.TypeEncoder {
+	refName := utils.StringSliceToPascalCase(append(fieldPaths, suffix))
+
+	statusScalarName := "StatusCode"
+	if _, ok := oc.schema.ScalarTypes[statusScalarName]; !ok {
+		oc.schema.ScalarTypes[statusScalarName] = *schema.NewScalarType()
+	}
+
+	object := schema.ObjectType{
+		Fields: schema.ObjectTypeFields{
+			"status_code": schema.ObjectField{
+				Type: schema.NewNamedType(statusScalarName).Encode(),
+			},
+		},
 	}
-	return schemaType, nil
+	for _, variant := range variants {
+		object.Fields[variant.fieldName] = schema.ObjectField{
+			Type: schema.NewNullableType(variant.resultType).Encode(),
+		}
+	}
+	oc.schema.ObjectTypes[refName] = object
+	return schema.NewNamedType(refName)
 }
 
 func (oc *openAPIv2Converter) convertComponentSchemas(schemaItem orderedmap.Pair[string, *base.SchemaProxy]) error {
 	typeValue := schemaItem.Value()
 	typeSchema := typeValue.Schema()
 
-	if typeSchema == nil || !slices.Contains(typeSchema.Type, "object") {
+	if typeSchema == nil || (!slices.Contains(typeSchema.Type, "object") && len(typeSchema.AllOf) == 0) {
 		return nil
 	}
 	_, _, err := oc.getSchemaType(typeSchema, "", []string{schemaItem.Key()})
 	return err
 }
 
+// flattenedObjectSchema is the set of properties and required fields that
+// result from resolving an allOf chain, Swagger 2's only composition
+// primitive. Parent properties are listed before the child's own so the
+// child always wins on name collisions.
+type flattenedObjectSchema struct {
+	properties []orderedmap.Pair[string, *base.SchemaProxy]
+	required   []string
+}
+
+// flattenAllOf merges every parent schema referenced through allOf into a
+// single flat property/required list, recursing so multi-level allOf chains
+// are fully inlined into the child object.
+func (oc *openAPIv2Converter) flattenAllOf(typeSchema *base.Schema) flattenedObjectSchema {
+	var result flattenedObjectSchema
+	for _, parentProxy := range typeSchema.AllOf {
+		parent := parentProxy.Schema()
+		if parent == nil {
+			continue
+		}
+		parentResult := oc.flattenAllOf(parent)
+		result.properties = append(result.properties, parentResult.properties...)
+		result.required = append(result.required, parentResult.required...)
+		if parent.Properties != nil {
+			for prop := parent.Properties.First(); prop != nil; prop = prop.Next() {
+				result.properties = append(result.properties, prop)
+			}
+		}
+		result.required = append(result.required, parent.Required...)
+	}
+	if typeSchema.Properties != nil {
+		for prop := typeSchema.Properties.First(); prop != nil; prop = prop.Next() {
+			result.properties = append(result.properties, prop)
+		}
+	}
+	result.required = append(result.required, typeSchema.Required...)
+	return result
+}
+
+// addDiscriminatorTag synthesizes the scalar tag field a discriminator
+// relies on to distinguish polymorphic variants, in case the allOf-flattened
+// object doesn't already declare it as a regular property, and records
+// discriminator.mapping's tag-value -> variant-type-name pairs so callers
+// can resolve which concrete type a given tag value decodes to.
+func (oc *openAPIv2Converter) addDiscriminatorTag(object *schema.ObjectType, typeResult *rest.TypeSchema, refName string, discriminator *base.Discriminator) {
+	propertyName := discriminator.PropertyName
+	if _, ok := object.Fields[propertyName]; !ok {
+		tagScalarName := refName + "Discriminator"
+		if _, ok := oc.schema.ScalarTypes[tagScalarName]; !ok {
+			oc.schema.ScalarTypes[tagScalarName] = *schema.NewScalarType()
+		}
+		object.Fields[propertyName] = schema.ObjectField{
+			Type: schema.NewNamedType(tagScalarName).Encode(),
+		}
+		typeResult.Properties[propertyName] = rest.TypeSchema{
+			Type: tagScalarName,
+		}
+	}
+
+	if discriminator.Mapping == nil || discriminator.Mapping.IsZero() {
+		return
+	}
+	variants := make(map[string]string)
+	for pair := discriminator.Mapping.First(); pair != nil; pair = pair.Next() {
+		variantName, err := oc.resolveSchemaRefName(pair.Value())
+		if err != nil || variantName == "" {
+			continue
+		}
+		variants[pair.Key()] = variantName
+	}
+	if len(variants) > 0 {
+		typeResult.DiscriminatorMapping = variants
+	}
+}
+
+// isReadOnlyOrWriteOnly reports whether a property is only ever present on
+// one side of the wire, per the readOnly/writeOnly keywords (kin-openapi#246).
+func isReadOnlyOrWriteOnly(propProxy *base.SchemaProxy) bool {
+	propSchema := propProxy.Schema()
+	if propSchema == nil {
+		return false
+	}
+	return (propSchema.ReadOnly != nil && *propSchema.ReadOnly) || (propSchema.WriteOnly != nil && *propSchema.WriteOnly)
+}
+
 func (oc *openAPIv2Converter) trimPathPrefix(input string) string {
 	if oc.ConvertOptions.TrimPrefix == "" {
 		return input
 	}
 	return strings.TrimPrefix(input, oc.ConvertOptions.TrimPrefix)
 }
+
+// mimeTypeAliases maps Swagger 2's short-form media type shorthands to their
+// full MIME types, mirroring swaggo's mimeTypeAliases.
+var mimeTypeAliases = map[string]string{
+	"json":                   rest.ContentTypeJSON,
+	"xml":                    "application/xml",
+	"mpfd":                   rest.ContentTypeMultipartFormData,
+	"x-www-form-urlencoded":  "application/x-www-form-urlencoded",
+	"urlencoded":             "application/x-www-form-urlencoded",
+	"octet-stream":           "application/octet-stream",
+	"png":                    "image/png",
+	"jpeg":                   "image/jpeg",
+	"jpg":                    "image/jpeg",
+	"gif":                    "image/gif",
+}
+
+// normalizeMimeTypes resolves known aliases to their full MIME type, leaving
+// anything else (already a full MIME type, or unrecognized) unchanged.
+func normalizeMimeTypes(mimeTypes []string) []string {
+	if len(mimeTypes) == 0 {
+		return nil
+	}
+	result := make([]string, len(mimeTypes))
+	for i, mimeType := range mimeTypes {
+		if alias, ok := mimeTypeAliases[mimeType]; ok {
+			result[i] = alias
+			continue
+		}
+		result[i] = mimeType
+	}
+	return result
+}
+
+// resolveMediaTypes normalizes an operation's consumes/produces, each falling
+// back independently to the document-level list when the operation omits it,
+// matching go-openapi analyzer semantics.
+func (oc *openAPIv2Converter) resolveMediaTypes(operationConsumes, operationProduces []string) (consumes []string, produces []string) {
+	consumes = normalizeMimeTypes(operationConsumes)
+	if len(consumes) == 0 {
+		consumes = normalizeMimeTypes(oc.docConsumes)
+	}
+	produces = normalizeMimeTypes(operationProduces)
+	if len(produces) == 0 {
+		produces = normalizeMimeTypes(oc.docProduces)
+	}
+	return consumes, produces
+}
+
+// addMediaTypeArgument adds a nullable string argument (`_content_type` or
+// `_accept`) so callers can pick among several documented media types; a
+// single documented type needs no argument since there's nothing to choose.
+func addMediaTypeArgument(arguments map[string]schema.ArgumentInfo, name string, mimeTypes []string, description string) {
+	if len(mimeTypes) <= 1 {
+		return
+	}
+	arguments[name] = schema.ArgumentInfo{
+		Type:        schema.NewNullableNamedType("String").Encode(),
+		Description: &description,
+	}
+}
+
+// upgradeV2ToV3 rewrites a Swagger 2 document into an OpenAPI 3 document so
+// callers can reuse the v3 converter for features Swagger 2 can't express,
+// rather than reimplementing them against the v2 model.
+func upgradeV2ToV3(input []byte) ([]byte, error) {
+	// YAML is a superset of JSON, so this also accepts the JSON specs the
+	// non-upgrade path handles through libopenapi.NewDocument
+	var v2Doc map[string]any
+	if err := yaml.Unmarshal(input, &v2Doc); err != nil {
+		return nil, err
+	}
+
+	v3Doc := map[string]any{
+		"openapi": "3.0.3",
+	}
+	for _, key := range []string{"info", "tags", "externalDocs", "security"} {
+		if value, ok := v2Doc[key]; ok {
+			v3Doc[key] = value
+		}
+	}
+
+	if servers := buildServersFromV2(v2Doc); len(servers) > 0 {
+		v3Doc["servers"] = servers
+	}
+
+	docConsumes := toStringSlice(v2Doc["consumes"])
+	docProduces := toStringSlice(v2Doc["produces"])
+	if paths, ok := v2Doc["paths"].(map[string]any); ok {
+		v3Doc["paths"] = upgradePathsV2ToV3(paths, docConsumes, docProduces)
+	}
+
+	if definitions, ok := v2Doc["definitions"]; ok {
+		setComponents(v3Doc, "schemas", definitions)
+	}
+	if parameters, ok := v2Doc["parameters"]; ok {
+		setComponents(v3Doc, "parameters", parameters)
+	}
+	if responses, ok := v2Doc["responses"]; ok {
+		setComponents(v3Doc, "responses", responses)
+	}
+	if securityDefinitions, ok := v2Doc["securityDefinitions"].(map[string]any); ok {
+		setComponents(v3Doc, "securitySchemes", upgradeSecuritySchemesV2ToV3(securityDefinitions))
+	}
+
+	rewriteRefs(v3Doc)
+
+	return json.Marshal(v3Doc)
+}
+
+// rewriteRefs walks a decoded document rewriting every `$ref` string in
+// place, so relocating definitions/parameters/responses under `components`
+// doesn't leave the pointers that targeted their old locations dangling.
+func rewriteRefs(value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		if ref, ok := v["$ref"].(string); ok {
+			v["$ref"] = rewriteRefPointer(ref)
+		}
+		for _, child := range v {
+			rewriteRefs(child)
+		}
+	case []any:
+		for _, child := range v {
+			rewriteRefs(child)
+		}
+	}
+}
+
+// rewriteRefPointer renames a v2 ref's container section to where
+// upgradeV2ToV3 relocated it under components.
+func rewriteRefPointer(ref string) string {
+	for _, rename := range [][2]string{
+		{"#/definitions/", "#/components/schemas/"},
+		{"#/parameters/", "#/components/parameters/"},
+		{"#/responses/", "#/components/responses/"},
+	} {
+		if strings.HasPrefix(ref, rename[0]) {
+			return rename[1] + strings.TrimPrefix(ref, rename[0])
+		}
+	}
+	return ref
+}
+
+// buildServersFromV2 folds Swagger 2's host/basePath/schemes triple into the
+// single base URL a v3 server entry expects.
+func buildServersFromV2(v2Doc map[string]any) []map[string]any {
+	host, _ := v2Doc["host"].(string)
+	if host == "" {
+		return nil
+	}
+	basePath, _ := v2Doc["basePath"].(string)
+	scheme := "https"
+	for _, s := range toStringSlice(v2Doc["schemes"]) {
+		if strings.HasPrefix(s, "http") {
+			scheme = s
+			break
+		}
+	}
+	return []map[string]any{
+		{"url": fmt.Sprintf("%s://%s%s", scheme, host, basePath)},
+	}
+}
+
+// upgradeSecuritySchemesV2ToV3 converts securityDefinitions entries to
+// components.securitySchemes, folding the oauth2 flow into the nested
+// `flows` map v3 expects and renaming the `accessCode` flow to
+// `authorizationCode`.
+func upgradeSecuritySchemesV2ToV3(definitions map[string]any) map[string]any {
+	result := make(map[string]any, len(definitions))
+	for name, rawScheme := range definitions {
+		scheme, ok := rawScheme.(map[string]any)
+		if !ok {
+			continue
+		}
+		upgraded := make(map[string]any, len(scheme))
+		for k, v := range scheme {
+			upgraded[k] = v
+		}
+		switch upgraded["type"] {
+		case "basic":
+			upgraded["type"] = "http"
+			upgraded["scheme"] = "basic"
+		case "oauth2":
+			flowName, _ := upgraded["flow"].(string)
+			switch flowName {
+			case "accessCode":
+				flowName = "authorizationCode"
+			case "application":
+				flowName = "clientCredentials"
+			}
+			flow := map[string]any{}
+			for _, key := range []string{"authorizationUrl", "tokenUrl", "scopes"} {
+				if v, ok := upgraded[key]; ok {
+					flow[key] = v
+				}
+				delete(upgraded, key)
+			}
+			delete(upgraded, "flow")
+			upgraded["flows"] = map[string]any{flowName: flow}
+		}
+		result[name] = upgraded
+	}
+	return result
+}
+
+// upgradePathsV2ToV3 upgrades every operation of every path item, leaving
+// non-operation keys (e.g. $ref, parameters shared across methods) untouched.
+func upgradePathsV2ToV3(paths map[string]any, docConsumes, docProduces []string) map[string]any {
+	result := make(map[string]any, len(paths))
+	for pathKey, rawItem := range paths {
+		pathItem, ok := rawItem.(map[string]any)
+		if !ok {
+			continue
+		}
+		upgradedItem := make(map[string]any, len(pathItem))
+		for method, rawValue := range pathItem {
+			operation, ok := rawValue.(map[string]any)
+			if !ok || !slices.Contains([]string{"get", "put", "post", "delete", "options", "head", "patch"}, method) {
+				upgradedItem[method] = rawValue
+				continue
+			}
+			upgradedItem[method] = upgradeOperationV2ToV3(operation, docConsumes, docProduces)
+		}
+		result[pathKey] = upgradedItem
+	}
+	return result
+}
+
+// upgradeOperationV2ToV3 maps `consumes`/`produces` and body/formData
+// parameters onto a v3 `requestBody`, and response `schema`s onto
+// content-typed `responses`.
+func upgradeOperationV2ToV3(operation map[string]any, docConsumes, docProduces []string) map[string]any {
+	upgraded := make(map[string]any, len(operation))
+	for k, v := range operation {
+		if k != "parameters" && k != "responses" && k != "consumes" && k != "produces" {
+			upgraded[k] = v
+		}
+	}
+
+	consumes := toStringSlice(operation["consumes"])
+	if len(consumes) == 0 {
+		consumes = docConsumes
+	}
+	produces := toStringSlice(operation["produces"])
+	if len(produces) == 0 {
+		produces = docProduces
+	}
+
+	var remainingParams []any
+	formDataProps := map[string]any{}
+	var formDataRequired []string
+	var requestBody map[string]any
+
+	for _, rawParam := range toAnySlice(operation["parameters"]) {
+		param, ok := rawParam.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch param["in"] {
+		case "body":
+			content := map[string]any{}
+			for _, mediaType := range mediaTypesOrDefault(consumes, rest.ContentTypeJSON) {
+				content[mediaType] = map[string]any{"schema": param["schema"]}
+			}
+			requestBody = map[string]any{
+				"description": param["description"],
+				"required":    param["required"],
+				"content":     content,
+			}
+		case "formData":
+			name, _ := param["name"].(string)
+			formDataProps[name] = toFormDataPropertySchema(param)
+			if required, _ := param["required"].(bool); required {
+				formDataRequired = append(formDataRequired, name)
+			}
+		default:
+			remainingParams = append(remainingParams, param)
+		}
+	}
+
+	if len(formDataProps) > 0 {
+		requestBody = map[string]any{
+			"content": map[string]any{
+				rest.ContentTypeMultipartFormData: map[string]any{
+					"schema": map[string]any{
+						"type":       "object",
+						"properties": formDataProps,
+						"required":   formDataRequired,
+					},
+				},
+			},
+		}
+	}
+
+	if remainingParams != nil {
+		upgraded["parameters"] = remainingParams
+	}
+	if requestBody != nil {
+		upgraded["requestBody"] = requestBody
+	}
+	if responses, ok := operation["responses"].(map[string]any); ok {
+		upgraded["responses"] = upgradeResponsesV2ToV3(responses, produces)
+	}
+
+	return upgraded
+}
+
+// toFormDataPropertySchema maps a formData parameter onto the object
+// property schema its multipart/form-data requestBody will carry it as.
+func toFormDataPropertySchema(param map[string]any) map[string]any {
+	propSchema := map[string]any{}
+	for _, key := range []string{"type", "format", "items", "enum", "default", "description"} {
+		if v, ok := param[key]; ok {
+			propSchema[key] = v
+		}
+	}
+	if param["type"] == "file" {
+		propSchema["type"] = "string"
+		propSchema["format"] = "binary"
+	}
+	return propSchema
+}
+
+// upgradeResponsesV2ToV3 moves each response's bare `schema` under
+// `content[mediaType].schema`, repeating it for every declared produces mime
+// type since v2 has no way to vary the schema by media type.
+func upgradeResponsesV2ToV3(responses map[string]any, produces []string) map[string]any {
+	mediaTypes := mediaTypesOrDefault(produces, rest.ContentTypeJSON)
+	result := make(map[string]any, len(responses))
+	for code, rawResp := range responses {
+		resp, ok := rawResp.(map[string]any)
+		if !ok {
+			result[code] = rawResp
+			continue
+		}
+		upgradedResp := make(map[string]any, len(resp))
+		for k, v := range resp {
+			if k != "schema" {
+				upgradedResp[k] = v
+			}
+		}
+		if respSchema, ok := resp["schema"]; ok {
+			content := map[string]any{}
+			for _, mediaType := range mediaTypes {
+				content[mediaType] = map[string]any{"schema": respSchema}
+			}
+			upgradedResp["content"] = content
+		}
+		result[code] = upgradedResp
+	}
+	return result
+}
+
+func toAnySlice(value any) []any {
+	items, _ := value.([]any)
+	return items
+}
+
+func toStringSlice(value any) []string {
+	items, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// mediaTypesOrDefault returns items, or a single-element slice of fallback
+// when items is empty, so callers always have at least one media type to
+// key their v3 `content` map by.
+func mediaTypesOrDefault(items []string, fallback string) []string {
+	if len(items) == 0 {
+		return []string{fallback}
+	}
+	return items
+}
+
+func setComponents(v3Doc map[string]any, key string, value any) {
+	components, ok := v3Doc["components"].(map[string]any)
+	if !ok {
+		components = map[string]any{}
+		v3Doc["components"] = components
+	}
+	components[key] = value
+}
+
+// resolveSchemaRefName derives the type name a $ref points at, unescaping
+// its JSON-Pointer segments (~1 -> "/", ~0 -> "~") instead of naively
+// splitting on "/", and accepting refs rooted at #/definitions, #/parameters
+// or #/responses alike so shared parameter/response sets aren't silently
+// dropped. A reference with a file/URL component ahead of the "#" is
+// internalized via ResolveExternalRefs and namespaced by its source so it
+// can't collide with a same-named definition in the root document.
+func (oc *openAPIv2Converter) resolveSchemaRefName(reference string) (string, error) {
+	if reference == "" {
+		return "", nil
+	}
+
+	filePart, pointerPart, _ := strings.Cut(reference, "#")
+
+	var name string
+	if pointerPart != "" {
+		segments := strings.Split(strings.TrimPrefix(pointerPart, "/"), "/")
+		for i, segment := range segments {
+			segments[i] = unescapeJSONPointerSegment(segment)
+		}
+		if len(segments) > 1 {
+			switch segments[0] {
+			case "definitions", "parameters", "responses":
+				segments = segments[1:]
+			}
+		}
+		if len(segments) == 0 || segments[len(segments)-1] == "" {
+			return "", fmt.Errorf("cannot parse type reference name: %s", reference)
+		}
+		name = segments[len(segments)-1]
+	}
+
+	if filePart == "" {
+		return name, nil
+	}
+
+	if !oc.ResolveExternalRefs {
+		// Without ResolveExternalRefs we can't pull in the external file, so fall
+		// back to the bare referenced name, same as the old last-segment
+		// behavior. This can collide with a local type of the same name, but
+		// dropping the whole path/definition over it is worse.
+		if name == "" {
+			return "", fmt.Errorf("cannot parse type reference name: %s", reference)
+		}
+		return name, nil
+	}
+	namespace, err := oc.internalizeExternalRef(filePart)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return namespace, nil
+	}
+	return namespace + utils.ToPascalCase(name), nil
+}
+
+// unescapeJSONPointerSegment decodes the two escape sequences defined by
+// RFC 6901: "~1" for "/" and "~0" for "~". The order matters, since a
+// literal "~01" must decode to "~1", not "/".
+func unescapeJSONPointerSegment(segment string) string {
+	segment = strings.ReplaceAll(segment, "~1", "/")
+	segment = strings.ReplaceAll(segment, "~0", "~")
+	return segment
+}
+
+// internalizeExternalRef loads an external schema file at most once per
+// source, merging every object definition it reaches into this schema's
+// ObjectTypes/ScalarTypes under a deterministic PascalCase namespace derived
+// from the file name, then returns that namespace for the caller to prefix
+// onto the referenced type's own name.
+func (oc *openAPIv2Converter) internalizeExternalRef(filePart string) (string, error) {
+	if oc.externalRefNamespaces == nil {
+		oc.externalRefNamespaces = map[string]string{}
+	}
+	if namespace, ok := oc.externalRefNamespaces[filePart]; ok {
+		return namespace, nil
+	}
+
+	raw, err := oc.readExternalRefFile(filePart)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve external reference %q: %w", filePart, err)
+	}
+
+	namespace := utils.ToPascalCase(strings.TrimSuffix(path.Base(filePart), path.Ext(filePart)))
+	oc.externalRefNamespaces[filePart] = namespace
+
+	document, err := libopenapi.NewDocument(raw)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse external reference %q: %w", filePart, err)
+	}
+	docModel, errs := document.BuildV2Model()
+	if docModel == nil {
+		if len(errs) > 0 {
+			return "", errs[0]
+		}
+		return "", fmt.Errorf("failed to build model for external reference %q", filePart)
+	}
+
+	if docModel.Model.Definitions != nil {
+		for cSchema := docModel.Model.Definitions.Definitions.First(); cSchema != nil; cSchema = cSchema.Next() {
+			typeSchema := cSchema.Value().Schema()
+			if typeSchema == nil || (!slices.Contains(typeSchema.Type, "object") && len(typeSchema.AllOf) == 0) {
+				continue
+			}
+			if _, _, err := oc.getSchemaType(typeSchema, "", []string{namespace, cSchema.Key()}); err != nil {
+				return "", fmt.Errorf("%s: %w", filePart, err)
+			}
+		}
+	}
+
+	return namespace, nil
+}
+
+// readExternalRefFile loads a $ref's file component from disk, or, when
+// BaseURL is set, resolves it as a URL relative to BaseURL and fetches it.
+func (oc *openAPIv2Converter) readExternalRefFile(filePart string) ([]byte, error) {
+	if oc.BaseURL == "" {
+		return os.ReadFile(filePart)
+	}
+
+	base, err := url.Parse(oc.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := url.Parse(filePart)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Get(base.ResolveReference(ref).String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}